@@ -45,16 +45,8 @@ func validatorRegistryRoot(validators []*ethpb.Validator) ([32]byte, error) {
 	if err != nil {
 		return [32]byte{}, errors.Wrap(err, "could not compute validator registry merkleization")
 	}
-	validatorsRootsBuf := new(bytes.Buffer)
-	if err := binary.Write(validatorsRootsBuf, binary.LittleEndian, uint64(len(validators))); err != nil {
-		return [32]byte{}, errors.Wrap(err, "could not marshal validator registry length")
-	}
 	// We need to mix in the length of the slice.
-	var validatorsRootsBufRoot [32]byte
-	copy(validatorsRootsBufRoot[:], validatorsRootsBuf.Bytes())
-	res := ssz.MixInLength(validatorsRootsRoot, validatorsRootsBufRoot[:])
-
-	return res, nil
+	return mixInValidatorLength(validatorsRootsRoot, uint64(len(validators)))
 }
 
 func hashValidatorHelper(validators []*ethpb.Validator, roots [][32]byte, j int, groupSize int, wg *sync.WaitGroup) {
@@ -109,9 +101,67 @@ func OptimizedValidatorRoots(validators []*ethpb.Validator) ([][32]byte, error)
 	return roots, nil
 }
 
-// ValidatorRegistryProof computes the merkle proof for a validator at a specific index
-// in the validator registry.
-func ValidatorRegistryProof(validators []*ethpb.Validator, index uint64) ([][32]byte, error) {
+// registryProofDepth is the fixed depth of the validator registry's SSZ
+// list merkleization, derived from its list limit rather than the number
+// of validators actually present, so any proof against it must pad out
+// to the same depth using the standard zero-hash chain.
+var registryProofDepth = bits.Len64(uint64(fieldparams.ValidatorRegistryLimit) - 1)
+
+// zeroHashes[i] is the root of a perfectly empty subtree of depth i.
+// zeroHashes[0] is the zero-value leaf itself; every other entry is the
+// hash of the previous entry with itself, following the same RFC
+// 6962-style padding used by ssz.BitwiseMerkleize.
+var zeroHashes [][32]byte
+
+func init() {
+	zeroHashes = make([][32]byte, registryProofDepth+1)
+	for i := 1; i <= registryProofDepth; i++ {
+		concat := append(zeroHashes[i-1][:], zeroHashes[i-1][:]...)
+		zeroHashes[i] = hash.Hash(concat)
+	}
+}
+
+// ValidatorProof is a merkle proof of inclusion for a single validator in
+// a BeaconState.validators registry: the validator's index, the total
+// registry length (needed to redo the SSZ mix-in-length step), and the
+// sibling chain padded up to registryProofDepth.
+type ValidatorProof struct {
+	Index  uint64
+	Length uint64
+	Hashes [][32]byte
+}
+
+// mixInValidatorLength applies the same "mix in the length of the slice"
+// postprocessing step that ValidatorRegistryRoot applies, so proof
+// verification can reproduce the real registry root.
+func mixInValidatorLength(root [32]byte, length uint64) ([32]byte, error) {
+	lengthBuf := new(bytes.Buffer)
+	if err := binary.Write(lengthBuf, binary.LittleEndian, length); err != nil {
+		return [32]byte{}, errors.Wrap(err, "could not marshal validator registry length")
+	}
+	var lengthRoot [32]byte
+	copy(lengthRoot[:], lengthBuf.Bytes())
+	return ssz.MixInLength(root, lengthRoot[:]), nil
+}
+
+// validatorLeafRoot reduces a single validator's 8 SSZ field roots down
+// to the single leaf root that enters the registry's merkle tree.
+func validatorLeafRoot(validator *ethpb.Validator) ([32]byte, error) {
+	roots, err := ValidatorFieldRoots(validator)
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "could not get validator field roots")
+	}
+	for i := 0; i < validatorTreeDepth; i++ {
+		roots = htr.VectorizedSha256(roots)
+	}
+	return roots[0], nil
+}
+
+// ValidatorRegistryProof computes a merkle proof of inclusion for the
+// validator at index in the registry, padded up to registryProofDepth and
+// including the mix-in-length step, so it verifies directly against the
+// root returned by ValidatorRegistryRoot.
+func ValidatorRegistryProof(validators []*ethpb.Validator, index uint64) (*ValidatorProof, error) {
 	if index >= uint64(len(validators)) {
 		return nil, errors.New("validator index out of bounds")
 	}
@@ -122,63 +172,76 @@ func ValidatorRegistryProof(validators []*ethpb.Validator, index uint64) ([][32]
 		return nil, errors.Wrap(err, "could not get validator roots")
 	}
 
-	depth := bits.Len64(uint64(len(validators) - 1)) // Calculate required depth
+	return validatorRegistryProofFromLeaves(roots, index)
+}
+
+// validatorRegistryProofFromLeaves builds a ValidatorProof directly from
+// already-computed validator leaf roots, rather than ethpb.Validator
+// objects, for callers that maintain their own cache of leaf hashes
+// (e.g. ValidatorRegistryHasher).
+func validatorRegistryProofFromLeaves(roots [][32]byte, index uint64) (*ValidatorProof, error) {
+	if index >= uint64(len(roots)) {
+		return nil, errors.New("validator index out of bounds")
+	}
 
-	// Generate proof
-	proof := make([][32]byte, depth)
+	// Walk up to the fixed registry-limit depth, padding any missing
+	// right-hand subtrees with the standard zero-hash chain.
+	hashes := make([][32]byte, registryProofDepth)
 	tmp := roots
-	for h := 0; h < depth; h++ {
-		// Get the sibling index at height "h"
-		idx := (index >> h) ^ 1
-		if idx < uint64(len(tmp)) {
-			proof[h] = tmp[idx]
+	for h := 0; h < registryProofDepth; h++ {
+		sibling := zeroHashes[h]
+		if idx := (index >> uint(h)) ^ 1; idx < uint64(len(tmp)) {
+			sibling = tmp[idx]
 		}
-
-		// Move up one level in the tree
-		newSize := (len(tmp) + 1) / 2
-		newTmp := make([][32]byte, newSize)
-		for i := 0; i < len(tmp)-1; i += 2 {
-			concat := append(tmp[i][:], tmp[i+1][:]...)
+		hashes[h] = sibling
+
+		newTmp := make([][32]byte, (len(tmp)+1)/2)
+		for i := 0; i < len(tmp); i += 2 {
+			right := zeroHashes[h]
+			if i+1 < len(tmp) {
+				right = tmp[i+1]
+			}
+			concat := append(tmp[i][:], right[:]...)
 			newTmp[i/2] = hash.Hash(concat)
 		}
-		// Handle odd number of elements
-		if len(tmp)%2 == 1 {
-			concat := append(tmp[len(tmp)-1][:], make([]byte, 32)...)
-			newTmp[len(newTmp)-1] = hash.Hash(concat)
-		}
 		tmp = newTmp
 	}
 
-	return proof, nil
+	return &ValidatorProof{
+		Index:  index,
+		Length: uint64(len(roots)),
+		Hashes: hashes,
+	}, nil
 }
 
-// VerifyValidatorProof verifies a merkle proof for a validator
-func VerifyValidatorProof(validator *ethpb.Validator, index uint64, proof [][32]byte, root [32]byte) (bool, error) {
-	// Get validator root
-	validatorRoots, err := ValidatorFieldRoots(validator)
-	if err != nil {
-		return false, errors.Wrap(err, "could not get validator field roots")
+// VerifyValidatorProof verifies a merkle proof of inclusion for a
+// validator against root, which is expected to be a real
+// ValidatorRegistryRoot.
+func VerifyValidatorProof(validator *ethpb.Validator, proof *ValidatorProof, root [32]byte) (bool, error) {
+	if proof == nil || len(proof.Hashes) != registryProofDepth {
+		return false, errors.New("invalid validator proof depth")
 	}
 
-	// Hash up to get single validator root
-	roots := validatorRoots
-	for i := 0; i < validatorTreeDepth; i++ {
-		roots = htr.VectorizedSha256(roots)
+	leaf, err := validatorLeafRoot(validator)
+	if err != nil {
+		return false, err
 	}
-	leaf := roots[0]
 
-	// Verify the proof
 	currentRoot := leaf
-	for i, proofElement := range proof {
-		position := (index >> uint(i)) & 1
-		if position == 1 {
-			concat := append(proofElement[:], currentRoot[:]...)
+	for h, sibling := range proof.Hashes {
+		if (proof.Index>>uint(h))&1 == 1 {
+			concat := append(sibling[:], currentRoot[:]...)
 			currentRoot = hash.Hash(concat)
 		} else {
-			concat := append(currentRoot[:], proofElement[:]...)
+			concat := append(currentRoot[:], sibling[:]...)
 			currentRoot = hash.Hash(concat)
 		}
 	}
 
-	return bytes.Equal(currentRoot[:], root[:]), nil
+	registryRoot, err := mixInValidatorLength(currentRoot, proof.Length)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(registryRoot[:], root[:]), nil
 }
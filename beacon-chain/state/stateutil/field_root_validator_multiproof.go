@@ -0,0 +1,220 @@
+package stateutil
+
+import (
+	"bytes"
+	"math/bits"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/prysm/v5/crypto/hash"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+// ValidatorMultiProof is a compressed merkle proof of inclusion for a set
+// of validators in the registry. It stores only the sibling hashes that
+// cannot be derived from the other requested indices, so proving many
+// validators at once is far cheaper than calling ValidatorRegistryProof
+// once per index.
+type ValidatorMultiProof struct {
+	Indices []uint64
+	Length  uint64
+	Hashes  [][32]byte
+}
+
+// genIndex returns the generalized index, at the fixed registry-limit
+// depth, of the leaf for validator index i.
+func genIndex(i uint64) uint64 {
+	return (uint64(1) << uint(registryProofDepth)) + i
+}
+
+// branchIndices returns the generalized indices of every sibling on the
+// path from gi up to the root, not including gi itself.
+func branchIndices(gi uint64) []uint64 {
+	o := []uint64{gi ^ 1}
+	for o[len(o)-1] > 1 {
+		o = append(o, (o[len(o)-1]/2)^1)
+	}
+	return o[:len(o)-1]
+}
+
+// pathIndices returns the generalized indices of gi and every one of its
+// ancestors, not including the root.
+func pathIndices(gi uint64) []uint64 {
+	o := []uint64{gi}
+	for o[len(o)-1] > 1 {
+		o = append(o, o[len(o)-1]/2)
+	}
+	return o[:len(o)-1]
+}
+
+// multiProofHelperIndices computes the minimal, sorted (descending) set
+// of generalized indices whose hashes a verifier needs to fold gis back
+// up to the root.
+func multiProofHelperIndices(gis []uint64) []uint64 {
+	helperSet := make(map[uint64]bool)
+	pathSet := make(map[uint64]bool)
+	for _, gi := range gis {
+		for _, h := range branchIndices(gi) {
+			helperSet[h] = true
+		}
+		for _, p := range pathIndices(gi) {
+			pathSet[p] = true
+		}
+	}
+	helpers := make([]uint64, 0, len(helperSet))
+	for h := range helperSet {
+		if !pathSet[h] {
+			helpers = append(helpers, h)
+		}
+	}
+	sort.Slice(helpers, func(i, j int) bool { return helpers[i] > helpers[j] })
+	return helpers
+}
+
+// registryLevels merkleizes roots into cached per-height subtree arrays,
+// once, so that the hash of any node can be read directly instead of
+// re-walked: levels[h][k] covers [k*2^h, (k+1)*2^h).
+func registryLevels(roots [][32]byte) [][][32]byte {
+	levels := make([][][32]byte, registryProofDepth+1)
+	levels[0] = roots
+	for h := 0; h < registryProofDepth; h++ {
+		cur := levels[h]
+		next := make([][32]byte, (len(cur)+1)/2)
+		for i := 0; i < len(cur); i += 2 {
+			right := zeroHashes[h]
+			if i+1 < len(cur) {
+				right = cur[i+1]
+			}
+			concat := append(cur[i][:], right[:]...)
+			next[i/2] = hash.Hash(concat)
+		}
+		levels[h+1] = next
+	}
+	return levels
+}
+
+// genIndexHash returns the hash of the node identified by the
+// generalized index gi, read directly out of levels (see
+// registryLevels), which is built once per proof rather than per helper.
+func genIndexHash(gi uint64, levels [][][32]byte) [32]byte {
+	level := bits.Len64(gi) - 1
+	height := registryProofDepth - level
+	position := gi - (uint64(1) << uint(level))
+	if position < uint64(len(levels[height])) {
+		return levels[height][position]
+	}
+	return zeroHashes[height]
+}
+
+// ValidatorRegistryMultiProof computes a compressed merkle multiproof of
+// inclusion for the validators at indices, padded up to registryProofDepth
+// and including the mix-in-length step, so it verifies directly against
+// the root returned by ValidatorRegistryRoot.
+func ValidatorRegistryMultiProof(vals []*ethpb.Validator, indices []uint64) (*ValidatorMultiProof, error) {
+	if len(indices) == 0 {
+		return nil, errors.New("no indices provided")
+	}
+	sorted := append([]uint64{}, indices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, idx := range sorted {
+		if idx >= uint64(len(vals)) {
+			return nil, errors.New("validator index out of bounds")
+		}
+		if i > 0 && sorted[i] == sorted[i-1] {
+			return nil, errors.New("duplicate validator index")
+		}
+	}
+
+	roots, err := OptimizedValidatorRoots(vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get validator roots")
+	}
+	levels := registryLevels(roots)
+
+	gis := make([]uint64, len(sorted))
+	for i, idx := range sorted {
+		gis[i] = genIndex(idx)
+	}
+
+	helperGis := multiProofHelperIndices(gis)
+	hashes := make([][32]byte, len(helperGis))
+	for i, gi := range helperGis {
+		hashes[i] = genIndexHash(gi, levels)
+	}
+
+	return &ValidatorMultiProof{
+		Indices: sorted,
+		Length:  uint64(len(vals)),
+		Hashes:  hashes,
+	}, nil
+}
+
+// VerifyValidatorMultiProof verifies a compressed multiproof of inclusion
+// for the given validators, keyed by registry index, against root, which
+// is expected to be a real ValidatorRegistryRoot.
+func VerifyValidatorMultiProof(validators map[uint64]*ethpb.Validator, proof *ValidatorMultiProof, root [32]byte) (bool, error) {
+	if proof == nil || len(proof.Indices) != len(validators) {
+		return false, errors.New("validator set does not match proof indices")
+	}
+
+	gis := make([]uint64, len(proof.Indices))
+	objects := make(map[uint64][32]byte, len(proof.Indices)+len(proof.Hashes))
+	for i, idx := range proof.Indices {
+		v, ok := validators[idx]
+		if !ok {
+			return false, errors.Errorf("missing validator for index %d", idx)
+		}
+		leaf, err := validatorLeafRoot(v)
+		if err != nil {
+			return false, err
+		}
+		gi := genIndex(idx)
+		gis[i] = gi
+		objects[gi] = leaf
+	}
+
+	helperGis := multiProofHelperIndices(gis)
+	if len(helperGis) != len(proof.Hashes) {
+		return false, errors.New("invalid multiproof: helper hash count mismatch")
+	}
+	for i, gi := range helperGis {
+		objects[gi] = proof.Hashes[i]
+	}
+
+	keys := make([]uint64, 0, len(objects))
+	for k := range objects {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] > keys[j] })
+
+	for pos := 0; pos < len(keys); pos++ {
+		k := keys[pos]
+		if k <= 1 {
+			continue
+		}
+		parent := k / 2
+		if _, ok := objects[parent]; ok {
+			continue
+		}
+		left, okLeft := objects[parent*2]
+		right, okRight := objects[parent*2+1]
+		if !okLeft || !okRight {
+			continue
+		}
+		concat := append(left[:], right[:]...)
+		objects[parent] = hash.Hash(concat)
+		keys = append(keys, parent)
+	}
+
+	validatorsRootsRoot, ok := objects[1]
+	if !ok {
+		return false, errors.New("invalid multiproof: could not reconstruct root")
+	}
+
+	registryRoot, err := mixInValidatorLength(validatorsRootsRoot, proof.Length)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(registryRoot[:], root[:]), nil
+}
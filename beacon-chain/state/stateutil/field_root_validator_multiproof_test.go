@@ -0,0 +1,73 @@
+package stateutil
+
+import (
+	"testing"
+
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+func TestValidatorRegistryMultiProof_RoundTrip(t *testing.T) {
+	vals := newTestValidators(20)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+
+	cases := [][]uint64{
+		{0},
+		{0, 1},
+		{0, 19},
+		{3, 7, 11, 17},
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+	}
+	for _, indices := range cases {
+		proof, err := ValidatorRegistryMultiProof(vals, indices)
+		if err != nil {
+			t.Fatalf("indices=%v: ValidatorRegistryMultiProof: %v", indices, err)
+		}
+		validators := make(map[uint64]*ethpb.Validator, len(indices))
+		for _, idx := range indices {
+			validators[idx] = vals[idx]
+		}
+		ok, err := VerifyValidatorMultiProof(validators, proof, root)
+		if err != nil {
+			t.Fatalf("indices=%v: VerifyValidatorMultiProof: %v", indices, err)
+		}
+		if !ok {
+			t.Fatalf("indices=%v: multiproof did not verify against the real registry root", indices)
+		}
+	}
+}
+
+func TestValidatorRegistryMultiProof_RejectsMismatchedValidatorSet(t *testing.T) {
+	vals := newTestValidators(8)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+	proof, err := ValidatorRegistryMultiProof(vals, []uint64{1, 2})
+	if err != nil {
+		t.Fatalf("ValidatorRegistryMultiProof: %v", err)
+	}
+	validators := map[uint64]*ethpb.Validator{1: vals[1], 2: vals[5]}
+	ok, err := VerifyValidatorMultiProof(validators, proof, root)
+	if err != nil {
+		t.Fatalf("VerifyValidatorMultiProof: %v", err)
+	}
+	if ok {
+		t.Fatal("multiproof verified against a swapped-in validator")
+	}
+}
+
+func TestValidatorRegistryMultiProof_RejectsDuplicateOrOutOfBoundsIndices(t *testing.T) {
+	vals := newTestValidators(4)
+	if _, err := ValidatorRegistryMultiProof(vals, []uint64{1, 1}); err == nil {
+		t.Fatal("expected an error for duplicate indices")
+	}
+	if _, err := ValidatorRegistryMultiProof(vals, []uint64{4}); err == nil {
+		t.Fatal("expected an error for an out-of-bounds index")
+	}
+	if _, err := ValidatorRegistryMultiProof(vals, nil); err == nil {
+		t.Fatal("expected an error for no indices")
+	}
+}
@@ -0,0 +1,89 @@
+package stateutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prysmaticlabs/prysm/v5/consensus-types/primitives"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+// newTestValidator builds a deterministic, distinct *ethpb.Validator for
+// seed, for use across this package's tests.
+func newTestValidator(seed byte) *ethpb.Validator {
+	pubKey := bytes.Repeat([]byte{seed}, 48)
+	withdrawalCreds := bytes.Repeat([]byte{seed ^ 0xff}, 32)
+	return &ethpb.Validator{
+		PublicKey:                  pubKey,
+		WithdrawalCredentials:      withdrawalCreds,
+		EffectiveBalance:           32000000000,
+		Slashed:                    false,
+		ActivationEligibilityEpoch: primitives.Epoch(seed),
+		ActivationEpoch:            primitives.Epoch(seed),
+		ExitEpoch:                  primitives.Epoch(1<<64 - 1),
+		WithdrawableEpoch:          primitives.Epoch(1<<64 - 1),
+	}
+}
+
+func newTestValidators(n int) []*ethpb.Validator {
+	vals := make([]*ethpb.Validator, n)
+	for i := range vals {
+		vals[i] = newTestValidator(byte(i + 1))
+	}
+	return vals
+}
+
+func TestValidatorRegistryProof_RoundTrip(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 8, 9, 16} {
+		vals := newTestValidators(n)
+		root, err := ValidatorRegistryRoot(vals)
+		if err != nil {
+			t.Fatalf("n=%d: ValidatorRegistryRoot: %v", n, err)
+		}
+		for index := 0; index < n; index++ {
+			proof, err := ValidatorRegistryProof(vals, uint64(index))
+			if err != nil {
+				t.Fatalf("n=%d index=%d: ValidatorRegistryProof: %v", n, index, err)
+			}
+			ok, err := VerifyValidatorProof(vals[index], proof, root)
+			if err != nil {
+				t.Fatalf("n=%d index=%d: VerifyValidatorProof: %v", n, index, err)
+			}
+			if !ok {
+				t.Fatalf("n=%d index=%d: proof did not verify against the real registry root", n, index)
+			}
+		}
+	}
+}
+
+func TestValidatorRegistryProof_RejectsWrongValidator(t *testing.T) {
+	vals := newTestValidators(4)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+	proof, err := ValidatorRegistryProof(vals, 1)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryProof: %v", err)
+	}
+	ok, err := VerifyValidatorProof(vals[2], proof, root)
+	if err != nil {
+		t.Fatalf("VerifyValidatorProof: %v", err)
+	}
+	if ok {
+		t.Fatal("proof for index 1 verified against validator at index 2")
+	}
+}
+
+func TestValidatorRegistryProof_IndexOutOfBounds(t *testing.T) {
+	vals := newTestValidators(3)
+	if _, err := ValidatorRegistryProof(vals, 3); err == nil {
+		t.Fatal("expected an error for an out-of-bounds index")
+	}
+}
+
+func TestValidatorRegistryProof_EmptyRegistry(t *testing.T) {
+	if _, err := ValidatorRegistryProof(nil, 0); err == nil {
+		t.Fatal("expected an error for an empty registry")
+	}
+}
@@ -0,0 +1,367 @@
+package stateutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/prysm/v5/crypto/hash"
+	"github.com/prysmaticlabs/prysm/v5/crypto/hash/htr"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+// LeafOp describes how a leaf's raw input values are pre-hashed down to
+// the single leaf that enters InnerOp's walk: PrehashSteps rounds of
+// Hasher, pairwise (e.g. a validator's 8 SSZ field roots take
+// validatorTreeDepth == 3 rounds).
+type LeafOp struct {
+	PrehashSteps int
+	Hasher       string
+}
+
+// InnerOp describes how two adjacent nodes are combined while walking up
+// the tree. SSZ merkleization always concatenates left then right and
+// hashes the result.
+type InnerOp struct {
+	ChildOrder string
+	Hasher     string
+}
+
+// ProofSpec is a versioned, hasher-agnostic description of how a proof
+// must be verified: the leaf pre-hash, the inner-node combine step, and
+// any trailing post-process (here, the SSZ mix-in-length step), kept
+// separate from the proof bytes so a verifier only needs these numbers,
+// not Prysm's merkleization conventions, to fold a proof.
+type ProofSpec struct {
+	Version     uint32
+	Hasher      string
+	LeafOp      LeafOp
+	InnerOp     InnerOp
+	MixInLength bool
+}
+
+// ValidatorLeafSpec is the canonical LeafOp for an individual
+// ethpb.Validator.
+func ValidatorLeafSpec() LeafOp {
+	return LeafOp{PrehashSteps: validatorTreeDepth, Hasher: "sha256"}
+}
+
+// ValidatorRegistryProofSpec is the canonical ProofSpec for a
+// ValidatorProof produced by ValidatorRegistryProof: sha256 inner nodes
+// padded to registryProofDepth, followed by the SSZ mix-in-length
+// post-process.
+func ValidatorRegistryProofSpec() *ProofSpec {
+	return &ProofSpec{
+		Version:     1,
+		Hasher:      "sha256",
+		LeafOp:      ValidatorLeafSpec(),
+		InnerOp:     InnerOp{ChildOrder: "concat(left, right)", Hasher: "sha256"},
+		MixInLength: true,
+	}
+}
+
+// ValidatorRegistryProofSpecComposed is ValidatorRegistryProofSpec with
+// PrehashSteps zeroed out, for a registry level composed on top of an
+// inner proof (e.g. ValidatorFieldProof) whose output is already a
+// single validator root rather than 8 raw field roots.
+func ValidatorRegistryProofSpecComposed() *ProofSpec {
+	spec := ValidatorRegistryProofSpec()
+	spec.LeafOp = LeafOp{PrehashSteps: 0, Hasher: "sha256"}
+	return spec
+}
+
+// ValidatorFieldProofSpec is the canonical ProofSpec for a proof of a
+// single validator field (e.g. withdrawal_credentials) against that
+// validator's own root: sha256 inner nodes over its validatorTreeDepth
+// field-root tree, with no leaf pre-hash (the field root is already a
+// leaf) and no mix-in-length step (a validator isn't an SSZ list).
+func ValidatorFieldProofSpec() *ProofSpec {
+	return &ProofSpec{
+		Version:     1,
+		Hasher:      "sha256",
+		LeafOp:      LeafOp{PrehashSteps: 0, Hasher: "sha256"},
+		InnerOp:     InnerOp{ChildOrder: "concat(left, right)", Hasher: "sha256"},
+		MixInLength: false,
+	}
+}
+
+// ValidatorFieldProof computes a merkle proof of inclusion for the field
+// at fieldIndex (see ValidatorFieldRoots) within v's own 8-leaf field
+// tree, wrapped with its canonical ValidatorFieldProofSpec so it can be
+// composed, via ComposeSpecs, with a ValidatorProof/ValidatorMultiProof.
+func ValidatorFieldProof(v *ethpb.Validator, fieldIndex int) (*StructuredValidatorProof, error) {
+	if fieldIndex < 0 || fieldIndex >= validatorFieldRoots {
+		return nil, errors.New("field index out of bounds")
+	}
+	roots, err := ValidatorFieldRoots(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get validator field roots")
+	}
+
+	hashes := make([][32]byte, validatorTreeDepth)
+	tmp := roots
+	for h := 0; h < validatorTreeDepth; h++ {
+		hashes[h] = tmp[(fieldIndex>>uint(h))^1]
+		next := make([][32]byte, len(tmp)/2)
+		for i := 0; i < len(tmp); i += 2 {
+			concat := append(tmp[i][:], tmp[i+1][:]...)
+			next[i/2] = hash.Hash(concat)
+		}
+		tmp = next
+	}
+
+	return &StructuredValidatorProof{
+		LeafIndex:  uint64(fieldIndex),
+		TotalCount: validatorFieldRoots,
+		Siblings:   hashes,
+		Spec:       ValidatorFieldProofSpec(),
+	}, nil
+}
+
+// ComposedProof is one level of a multi-level StructuredValidatorProof
+// chain, annotated with the root it is expected to produce so
+// VerifyComposedProof can fold each level's output straight into the
+// next level's leaf.
+type ComposedProof struct {
+	Proof *StructuredValidatorProof
+	Root  [32]byte
+}
+
+// ComposeSpecs threads a sequence of StructuredValidatorProofs together,
+// innermost first (e.g. a validator field, then the registry), into the
+// ComposedProof chain VerifyComposedProof walks. leafRoots holds the raw
+// values the innermost proof's LeafOp reduces down to its leaf; every
+// subsequent level's leaf is the single root the previous level folded
+// to.
+func ComposeSpecs(proofs []*StructuredValidatorProof, leafRoots [][32]byte) ([]ComposedProof, error) {
+	if len(proofs) == 0 {
+		return nil, errors.New("no proofs provided")
+	}
+
+	composed := make([]ComposedProof, len(proofs))
+	current := leafRoots
+	for i, proof := range proofs {
+		if proof == nil || proof.Spec == nil {
+			return nil, errors.Errorf("missing proof or spec at level %d", i)
+		}
+		root, err := foldStructuredProof(proof.Spec, proof, current)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not fold proof at level %d", i)
+		}
+		composed[i] = ComposedProof{Proof: proof, Root: root}
+		current = [][32]byte{root}
+	}
+	return composed, nil
+}
+
+// StructuredValidatorProof is a self-describing, wire-friendly wrapper
+// around a ValidatorProof: it carries the ProofSpec needed to verify it
+// alongside the sibling chain, so a downstream verifier does not need
+// out-of-band knowledge of Prysm's merkleization conventions.
+type StructuredValidatorProof struct {
+	LeafIndex  uint64
+	TotalCount uint64
+	Siblings   [][32]byte
+	Spec       *ProofSpec
+}
+
+// NewStructuredValidatorProof wraps a ValidatorProof produced by
+// ValidatorRegistryProof with its canonical ProofSpec, for standalone use
+// against a validator's raw field roots.
+func NewStructuredValidatorProof(proof *ValidatorProof) *StructuredValidatorProof {
+	return &StructuredValidatorProof{
+		LeafIndex:  proof.Index,
+		TotalCount: proof.Length,
+		Siblings:   proof.Hashes,
+		Spec:       ValidatorRegistryProofSpec(),
+	}
+}
+
+// NewComposedStructuredValidatorProof wraps a ValidatorProof the same
+// way as NewStructuredValidatorProof, but with
+// ValidatorRegistryProofSpecComposed, for use as a non-innermost level of
+// a ComposeSpecs chain, where the leaf entering the registry tree is
+// already a single root rather than 8 raw field roots.
+func NewComposedStructuredValidatorProof(proof *ValidatorProof) *StructuredValidatorProof {
+	return &StructuredValidatorProof{
+		LeafIndex:  proof.Index,
+		TotalCount: proof.Length,
+		Siblings:   proof.Hashes,
+		Spec:       ValidatorRegistryProofSpecComposed(),
+	}
+}
+
+const sha256HasherID uint32 = 1
+
+// Marshal encodes a StructuredValidatorProof as a flat, versioned byte
+// stream: the ProofSpec header, then the leaf index, total count,
+// sibling count, and the sibling hashes themselves.
+func (p *StructuredValidatorProof) Marshal() ([]byte, error) {
+	if p.Spec == nil {
+		return nil, errors.New("missing proof spec")
+	}
+	if p.Spec.Hasher != "sha256" {
+		return nil, errors.Errorf("unsupported hasher %q", p.Spec.Hasher)
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{
+		p.Spec.Version,
+		sha256HasherID,
+		uint32(p.Spec.LeafOp.PrehashSteps),
+		p.Spec.MixInLength,
+		p.LeafIndex,
+		p.TotalCount,
+		uint32(len(p.Siblings)),
+	}
+	for _, f := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, f); err != nil {
+			return nil, errors.Wrap(err, "could not marshal proof header")
+		}
+	}
+	for _, s := range p.Siblings {
+		if _, err := buf.Write(s[:]); err != nil {
+			return nil, errors.Wrap(err, "could not marshal proof sibling")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a StructuredValidatorProof produced by Marshal.
+func (p *StructuredValidatorProof) Unmarshal(data []byte) error {
+	r := bytes.NewReader(data)
+	var version, hasherID, prehashSteps, siblingCount uint32
+	var mixInLength bool
+	var leafIndex, totalCount uint64
+	fields := []interface{}{&version, &hasherID, &prehashSteps, &mixInLength, &leafIndex, &totalCount, &siblingCount}
+	for _, f := range fields {
+		if err := binary.Read(r, binary.LittleEndian, f); err != nil {
+			return errors.Wrap(err, "could not unmarshal proof header")
+		}
+	}
+	if hasherID != sha256HasherID {
+		return errors.Errorf("unsupported hasher id %d", hasherID)
+	}
+
+	siblings := make([][32]byte, siblingCount)
+	for i := range siblings {
+		if _, err := io.ReadFull(r, siblings[i][:]); err != nil {
+			return errors.Wrap(err, "could not unmarshal proof sibling")
+		}
+	}
+
+	p.Spec = &ProofSpec{
+		Version:     version,
+		Hasher:      "sha256",
+		LeafOp:      LeafOp{PrehashSteps: int(prehashSteps), Hasher: "sha256"},
+		InnerOp:     InnerOp{ChildOrder: "concat(left, right)", Hasher: "sha256"},
+		MixInLength: mixInLength,
+	}
+	p.LeafIndex = leafIndex
+	p.TotalCount = totalCount
+	p.Siblings = siblings
+	return nil
+}
+
+// applyLeafOp reduces leafRoots down to the single leaf value that
+// enters a spec's InnerOp sibling walk, purely from the numbers in op:
+// PrehashSteps == 0 expects leafRoots to already be that single value;
+// PrehashSteps > 0 expects the 2^PrehashSteps raw values it is reduced
+// from via that many rounds of pairwise sha256 (the same reduction
+// validatorLeafRoot performs for a validator's field roots, driven here
+// only by op).
+func applyLeafOp(op LeafOp, leafRoots [][32]byte) ([32]byte, error) {
+	if op.Hasher != "sha256" {
+		return [32]byte{}, errors.Errorf("unsupported hasher %q", op.Hasher)
+	}
+	if op.PrehashSteps == 0 {
+		if len(leafRoots) != 1 {
+			return [32]byte{}, errors.Errorf("leaf op expects 1 root, got %d", len(leafRoots))
+		}
+		return leafRoots[0], nil
+	}
+	if want := 1 << uint(op.PrehashSteps); len(leafRoots) != want {
+		return [32]byte{}, errors.Errorf("leaf op expects %d roots, got %d", want, len(leafRoots))
+	}
+	tmp := leafRoots
+	for i := 0; i < op.PrehashSteps; i++ {
+		tmp = htr.VectorizedSha256(tmp)
+	}
+	return tmp[0], nil
+}
+
+// foldStructuredProof walks proof according to spec and returns the
+// root it produces from leafRoots, reducing leafRoots down to a single
+// leaf via spec.LeafOp before walking proof.Siblings.
+func foldStructuredProof(spec *ProofSpec, proof *StructuredValidatorProof, leafRoots [][32]byte) ([32]byte, error) {
+	if spec.Hasher != "sha256" || spec.InnerOp.Hasher != "sha256" {
+		return [32]byte{}, errors.Errorf("unsupported hasher %q", spec.Hasher)
+	}
+
+	current, err := applyLeafOp(spec.LeafOp, leafRoots)
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "could not apply leaf op")
+	}
+
+	for h, sibling := range proof.Siblings {
+		if (proof.LeafIndex>>uint(h))&1 == 1 {
+			current = hash.Hash(append(sibling[:], current[:]...))
+		} else {
+			current = hash.Hash(append(current[:], sibling[:]...))
+		}
+	}
+
+	if spec.MixInLength {
+		current, err = mixInValidatorLength(current, proof.TotalCount)
+		if err != nil {
+			return [32]byte{}, err
+		}
+	}
+
+	return current, nil
+}
+
+// VerifyStructuredProof walks proof according to its own spec and checks
+// it against root, given leafRoots (see applyLeafOp). It is a
+// single-level special case of VerifyComposedProof, kept for callers
+// that only ever need to verify one level (e.g. a bare
+// ValidatorRegistryProof wrapped via NewStructuredValidatorProof).
+func VerifyStructuredProof(spec *ProofSpec, proof *StructuredValidatorProof, root [32]byte, leafRoots [][32]byte) (bool, error) {
+	if spec == nil || proof == nil {
+		return false, errors.New("missing spec or proof")
+	}
+	got, err := foldStructuredProof(spec, proof, leafRoots)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(got[:], root[:]), nil
+}
+
+// VerifyComposedProof walks a ComposedProof chain produced by
+// ComposeSpecs, folding leafRoots through every level in turn (each
+// level after the first receives the previous level's verified root as
+// its sole leaf root), and checks each level's resulting root against
+// what ComposeSpecs recorded for it and the final level against root.
+func VerifyComposedProof(composed []ComposedProof, root [32]byte, leafRoots [][32]byte) (bool, error) {
+	if len(composed) == 0 {
+		return false, errors.New("no composed proof levels provided")
+	}
+
+	current := leafRoots
+	for i, level := range composed {
+		if level.Proof == nil || level.Proof.Spec == nil {
+			return false, errors.Errorf("missing proof or spec at level %d", i)
+		}
+		got, err := foldStructuredProof(level.Proof.Spec, level.Proof, current)
+		if err != nil {
+			return false, errors.Wrapf(err, "could not fold proof at level %d", i)
+		}
+		if !bytes.Equal(got[:], level.Root[:]) {
+			return false, nil
+		}
+		current = [][32]byte{got}
+	}
+
+	return bytes.Equal(current[0][:], root[:]), nil
+}
@@ -0,0 +1,164 @@
+package stateutil
+
+import "testing"
+
+func TestStructuredValidatorProof_MarshalUnmarshalRoundTrip(t *testing.T) {
+	vals := newTestValidators(5)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+	proof, err := ValidatorRegistryProof(vals, 2)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryProof: %v", err)
+	}
+	structured := NewStructuredValidatorProof(proof)
+
+	data, err := structured.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var got StructuredValidatorProof
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	// Only ValidatorFieldRoots (the SSZ field decomposition itself) is
+	// supplied out of band; got.Spec drives the reduction from those 8
+	// raw roots down to the registry leaf.
+	fieldRoots, err := ValidatorFieldRoots(vals[2])
+	if err != nil {
+		t.Fatalf("ValidatorFieldRoots: %v", err)
+	}
+	ok, err := VerifyStructuredProof(got.Spec, &got, root, fieldRoots)
+	if err != nil {
+		t.Fatalf("VerifyStructuredProof: %v", err)
+	}
+	if !ok {
+		t.Fatal("round-tripped structured proof did not verify")
+	}
+}
+
+// TestComposeSpecs_FieldToRegistry is the end-to-end scenario the request
+// asked for: a caller composes a field-level proof with a registry-level
+// proof to prove a single validator field against the registry root,
+// without re-deriving the validator's own root by hand.
+func TestComposeSpecs_FieldToRegistry(t *testing.T) {
+	vals := newTestValidators(9)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+
+	const fieldIndex = 1 // WithdrawalCredentials
+	index := uint64(6)
+
+	fieldProof, err := ValidatorFieldProof(vals[index], fieldIndex)
+	if err != nil {
+		t.Fatalf("ValidatorFieldProof: %v", err)
+	}
+	registryProof, err := ValidatorRegistryProof(vals, index)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryProof: %v", err)
+	}
+	// Composed atop fieldProof, so the registry level's leaf is already a
+	// single, fully-reduced root, not 8 raw field roots.
+	structuredRegistryProof := NewComposedStructuredValidatorProof(registryProof)
+
+	fieldRoots, err := ValidatorFieldRoots(vals[index])
+	if err != nil {
+		t.Fatalf("ValidatorFieldRoots: %v", err)
+	}
+	leafRoots := [][32]byte{fieldRoots[fieldIndex]}
+
+	composed, err := ComposeSpecs([]*StructuredValidatorProof{fieldProof, structuredRegistryProof}, leafRoots)
+	if err != nil {
+		t.Fatalf("ComposeSpecs: %v", err)
+	}
+	if len(composed) != 2 {
+		t.Fatalf("len(composed) = %d, want 2", len(composed))
+	}
+
+	ok, err := VerifyComposedProof(composed, root, leafRoots)
+	if err != nil {
+		t.Fatalf("VerifyComposedProof: %v", err)
+	}
+	if !ok {
+		t.Fatal("composed field-to-registry proof did not verify against the real registry root")
+	}
+}
+
+func TestComposeSpecs_RejectsEmptyAndMalformedInput(t *testing.T) {
+	if _, err := ComposeSpecs(nil, nil); err == nil {
+		t.Fatal("expected an error for no proofs")
+	}
+	if _, err := ComposeSpecs([]*StructuredValidatorProof{nil}, nil); err == nil {
+		t.Fatal("expected an error for a nil proof level")
+	}
+}
+
+func TestVerifyComposedProof_RejectsWrongLeaf(t *testing.T) {
+	vals := newTestValidators(4)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+	registryProof, err := ValidatorRegistryProof(vals, 0)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryProof: %v", err)
+	}
+	structured := NewComposedStructuredValidatorProof(registryProof)
+	leaf, err := validatorLeafRoot(vals[0])
+	if err != nil {
+		t.Fatalf("validatorLeafRoot: %v", err)
+	}
+	composed, err := ComposeSpecs([]*StructuredValidatorProof{structured}, [][32]byte{leaf})
+	if err != nil {
+		t.Fatalf("ComposeSpecs: %v", err)
+	}
+
+	wrongLeaf, err := validatorLeafRoot(vals[1])
+	if err != nil {
+		t.Fatalf("validatorLeafRoot: %v", err)
+	}
+	ok, err := VerifyComposedProof(composed, root, [][32]byte{wrongLeaf})
+	if err != nil {
+		t.Fatalf("VerifyComposedProof: %v", err)
+	}
+	if ok {
+		t.Fatal("composed proof verified with the wrong starting leaf")
+	}
+}
+
+// TestVerifyStructuredProof_ExecutesLeafOp is a regression test: the
+// spec's LeafOp must actually be executed from raw field roots, not
+// merely documented, so a verifier only needs ProofSpec's numbers (not
+// out-of-band Prysm knowledge) to fold a proof.
+func TestVerifyStructuredProof_ExecutesLeafOp(t *testing.T) {
+	vals := newTestValidators(3)
+	root, err := ValidatorRegistryRoot(vals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+	proof, err := ValidatorRegistryProof(vals, 1)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryProof: %v", err)
+	}
+	structured := NewStructuredValidatorProof(proof)
+
+	fieldRoots, err := ValidatorFieldRoots(vals[1])
+	if err != nil {
+		t.Fatalf("ValidatorFieldRoots: %v", err)
+	}
+	ok, err := VerifyStructuredProof(structured.Spec, structured, root, fieldRoots)
+	if err != nil {
+		t.Fatalf("VerifyStructuredProof: %v", err)
+	}
+	if !ok {
+		t.Fatal("VerifyStructuredProof did not correctly reduce raw field roots via LeafOp")
+	}
+
+	if _, err := VerifyStructuredProof(structured.Spec, structured, root, fieldRoots[:1]); err == nil {
+		t.Fatal("expected an error for the wrong number of leaf roots")
+	}
+}
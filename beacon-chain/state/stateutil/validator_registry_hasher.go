@@ -0,0 +1,287 @@
+package stateutil
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/prysm/v5/crypto/hash/htr"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+// hasherLevelBits partitions registryProofDepth into a schedule of branch
+// widths, from the root down to individual validators: a wide 1024-way
+// branch at the root followed by several narrower 64-way levels. Every
+// entry must be a count of bits, and the entries must sum to
+// registryProofDepth (10 + 6*5 == 40).
+var hasherLevelBits = []int{10, 6, 6, 6, 6, 6}
+
+// hasherLevelDepthBelow[i] is the number of binary tree levels below each
+// child of a node at schedule index i, i.e. the size (in bits) of the
+// subtree each of its children represents. It is used to pick the right
+// zero-hash when a child is absent.
+var hasherLevelDepthBelow []int
+
+func init() {
+	total := 0
+	for _, b := range hasherLevelBits {
+		total += b
+	}
+	if total != registryProofDepth {
+		panic("stateutil: hasherLevelBits does not sum to registryProofDepth")
+	}
+
+	hasherLevelDepthBelow = make([]int, len(hasherLevelBits))
+	below := 0
+	for i := len(hasherLevelBits) - 1; i >= 0; i-- {
+		hasherLevelDepthBelow[i] = below
+		below += hasherLevelBits[i]
+	}
+}
+
+// radixNode is a single node of the registry radix tree. A node either
+// owns children one schedule level down (internal node), or, at the
+// deepest schedule level, owns cached per-validator leaf hashes directly.
+type radixNode struct {
+	hash     [32]byte
+	dirty    bool
+	children map[uint64]*radixNode
+	leaves   map[uint64][32]byte
+}
+
+// ValidatorRegistryHasher incrementally maintains a radix tree of cached
+// subtree roots for a BeaconState.validators registry, keyed by
+// generalized index, so Root() only re-hashes the subtrees touched by
+// Update/Append/Delete since the last call. It also maintains a
+// PrefixFrontier incrementally: peaks holds the current Merkle Mountain
+// Range decomposition, kept current in O(1) amortized time per Append.
+// Update and Delete can retroactively change an already-committed leaf,
+// which the peak merge in pushPrefixPeak assumes never happens, so they
+// mark peaksDirty instead and Frontier falls back to an O(n) rebuild.
+type ValidatorRegistryHasher struct {
+	mu         sync.Mutex
+	root       *radixNode
+	validators []*ethpb.Validator
+	peaks      []PrefixPeak
+	peaksDirty bool
+}
+
+// NewValidatorRegistryHasher builds a hasher seeded with validators,
+// marking the root dirty so the first Root() call establishes the full
+// cache even when validators is empty (setLeaf never runs to mark it
+// dirty on its own in that case).
+func NewValidatorRegistryHasher(validators []*ethpb.Validator) (*ValidatorRegistryHasher, error) {
+	h := &ValidatorRegistryHasher{
+		root:       &radixNode{dirty: true},
+		validators: make([]*ethpb.Validator, len(validators)),
+	}
+	for i, v := range validators {
+		leaf, err := validatorLeafRoot(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not compute leaf root for validator %d", i)
+		}
+		h.validators[i] = v
+		h.setLeaf(uint64(i), leaf)
+		h.peaks = pushPrefixPeak(h.peaks, leaf)
+	}
+	return h, nil
+}
+
+// path returns, for each schedule level, the local child index a
+// validator at index resolves to at that level.
+func path(index uint64) []uint64 {
+	keys := make([]uint64, len(hasherLevelBits))
+	for i, b := range hasherLevelBits {
+		mask := uint64(1)<<uint(b) - 1
+		keys[i] = (index >> uint(hasherLevelDepthBelow[i])) & mask
+	}
+	return keys
+}
+
+// setLeaf writes the cached leaf hash for index, creating any radix
+// nodes needed along the way and marking the whole path dirty.
+func (h *ValidatorRegistryHasher) setLeaf(index uint64, leaf [32]byte) {
+	keys := path(index)
+	node := h.root
+	node.dirty = true
+	for i := 0; i < len(keys)-1; i++ {
+		if node.children == nil {
+			node.children = make(map[uint64]*radixNode)
+		}
+		child, ok := node.children[keys[i]]
+		if !ok {
+			child = &radixNode{}
+			node.children[keys[i]] = child
+		}
+		child.dirty = true
+		node = child
+	}
+	if node.leaves == nil {
+		node.leaves = make(map[uint64][32]byte)
+	}
+	node.leaves[keys[len(keys)-1]] = leaf
+}
+
+// Update replaces the validator at index and marks the affected subtree
+// dirty so the next Root() call re-hashes only that path. It also marks
+// the MMR peaks dirty: Update can change a leaf that an existing peak
+// already merged over, which Frontier's incremental maintenance cannot
+// patch up in place, so it falls back to a full O(n) rebuild on the next
+// Frontier call.
+func (h *ValidatorRegistryHasher) Update(index uint64, v *ethpb.Validator) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if index >= uint64(len(h.validators)) {
+		return errors.New("validator index out of bounds")
+	}
+	leaf, err := validatorLeafRoot(v)
+	if err != nil {
+		return errors.Wrap(err, "could not compute validator leaf root")
+	}
+	h.validators[index] = v
+	h.setLeaf(index, leaf)
+	h.peaksDirty = true
+	return nil
+}
+
+// Append adds v to the end of the registry and returns its new index.
+func (h *ValidatorRegistryHasher) Append(v *ethpb.Validator) (uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	leaf, err := validatorLeafRoot(v)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not compute validator leaf root")
+	}
+	index := uint64(len(h.validators))
+	h.validators = append(h.validators, v)
+	h.setLeaf(index, leaf)
+	if !h.peaksDirty {
+		h.peaks = pushPrefixPeak(h.peaks, leaf)
+	}
+	return index, nil
+}
+
+// Delete tombstones the validator at index, zeroing its leaf. The
+// registry itself is conceptually append-only (the real beacon chain
+// never shrinks BeaconState.validators), so this does not change the
+// registry length; it only lets a slot be cleared, e.g. to back out a
+// speculative Append. Like Update, it marks the MMR peaks dirty, since it
+// can zero a leaf an existing peak already merged over.
+func (h *ValidatorRegistryHasher) Delete(index uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if index >= uint64(len(h.validators)) {
+		return errors.New("validator index out of bounds")
+	}
+	h.validators[index] = nil
+	h.setLeaf(index, zeroHashes[0])
+	h.peaksDirty = true
+	return nil
+}
+
+// nodeRoot returns the cached root of node, which sits at the given
+// schedule level, recomputing it with htr.VectorizedSha256 first if it is
+// dirty. Clean subtrees are returned straight from cache.
+func nodeRoot(node *radixNode, level int) [32]byte {
+	if !node.dirty {
+		return node.hash
+	}
+
+	width := uint64(1) << uint(hasherLevelBits[level])
+	vals := make([][32]byte, width)
+
+	if level == len(hasherLevelBits)-1 {
+		for p := uint64(0); p < width; p++ {
+			if v, ok := node.leaves[p]; ok {
+				vals[p] = v
+			} else {
+				vals[p] = zeroHashes[0]
+			}
+		}
+	} else {
+		childDepth := hasherLevelDepthBelow[level]
+		for p := uint64(0); p < width; p++ {
+			child, ok := node.children[p]
+			if !ok {
+				vals[p] = zeroHashes[childDepth]
+				continue
+			}
+			vals[p] = nodeRoot(child, level+1)
+		}
+	}
+
+	for r := 0; r < hasherLevelBits[level]; r++ {
+		vals = htr.VectorizedSha256(vals)
+	}
+	node.hash = vals[0]
+	node.dirty = false
+	return node.hash
+}
+
+// Root returns the current validator registry root, re-hashing only the
+// subtrees dirtied since the last call.
+func (h *ValidatorRegistryHasher) Root() ([32]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	registryRoot := nodeRoot(h.root, 0)
+	return mixInValidatorLength(registryRoot, uint64(len(h.validators)))
+}
+
+// leafAt returns the cached leaf hash for index, or the zero-hash if it
+// was never set (which should not happen for any index < len(validators)
+// once Update/Append/Delete always keep the cache in sync).
+func (h *ValidatorRegistryHasher) leafAt(index uint64) [32]byte {
+	keys := path(index)
+	node := h.root
+	for i := 0; i < len(keys)-1; i++ {
+		child, ok := node.children[keys[i]]
+		if !ok {
+			return zeroHashes[0]
+		}
+		node = child
+	}
+	if leaf, ok := node.leaves[keys[len(keys)-1]]; ok {
+		return leaf
+	}
+	return zeroHashes[0]
+}
+
+// leafRoots reads every validator's cached leaf hash out of the radix
+// tree, in index order.
+func (h *ValidatorRegistryHasher) leafRoots() [][32]byte {
+	roots := make([][32]byte, len(h.validators))
+	for i := range roots {
+		roots[i] = h.leafAt(uint64(i))
+	}
+	return roots
+}
+
+// Proof returns a merkle proof of inclusion for the validator at index.
+// It reads leaf hashes out of the radix cache rather than re-deriving
+// them from h.validators, so a Delete'd slot elsewhere (which stores a
+// nil *ethpb.Validator) can never cause Proof to panic while deriving
+// field roots for an unrelated index.
+func (h *ValidatorRegistryHasher) Proof(index uint64) (*ValidatorProof, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return validatorRegistryProofFromLeaves(h.leafRoots(), index)
+}
+
+// Frontier returns the current PrefixFrontier (MMR peaks) of the
+// registry, for use with ValidatorRegistryPrefixProof. This is normally
+// O(1), since peaks are maintained incrementally on Append; it falls
+// back to an O(n) rebuild the first time it's called after an
+// intervening Update or Delete, since either can retroactively change a
+// leaf an existing peak already merged over.
+func (h *ValidatorRegistryHasher) Frontier() (*PrefixFrontier, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.peaksDirty {
+		var peaks []PrefixPeak
+		for _, leaf := range h.leafRoots() {
+			peaks = pushPrefixPeak(peaks, leaf)
+		}
+		h.peaks = peaks
+		h.peaksDirty = false
+	}
+	return &PrefixFrontier{Length: uint64(len(h.validators)), Peaks: h.peaks}, nil
+}
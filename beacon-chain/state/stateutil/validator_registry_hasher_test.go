@@ -0,0 +1,181 @@
+package stateutil
+
+import (
+	"bytes"
+	"testing"
+
+	fieldparams "github.com/prysmaticlabs/prysm/v5/config/fieldparams"
+	"github.com/prysmaticlabs/prysm/v5/encoding/ssz"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+// TestValidatorRegistryHasher_EmptyRegistryRoot is a regression test: the
+// root radixNode's zero-value dirty field is false, so a hasher seeded
+// with zero validators used to return the zero-value [32]byte{} from
+// Root() instead of the real empty-registry root, since nothing had ever
+// marked it dirty.
+func TestValidatorRegistryHasher_EmptyRegistryRoot(t *testing.T) {
+	h, err := NewValidatorRegistryHasher(nil)
+	if err != nil {
+		t.Fatalf("NewValidatorRegistryHasher: %v", err)
+	}
+	got, err := h.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+	want, err := ValidatorRegistryRoot(nil)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+	if !bytes.Equal(got[:], want[:]) {
+		t.Fatal("empty hasher root does not match ValidatorRegistryRoot")
+	}
+}
+
+func TestValidatorRegistryHasher_RootMatchesTreeRoot(t *testing.T) {
+	vals := newTestValidators(10)
+	h, err := NewValidatorRegistryHasher(vals)
+	if err != nil {
+		t.Fatalf("NewValidatorRegistryHasher: %v", err)
+	}
+
+	assertRootMatches := func(step string) {
+		t.Helper()
+		got, err := h.Root()
+		if err != nil {
+			t.Fatalf("%s: Root: %v", step, err)
+		}
+		want, err := ValidatorRegistryRoot(vals)
+		if err != nil {
+			t.Fatalf("%s: ValidatorRegistryRoot: %v", step, err)
+		}
+		if !bytes.Equal(got[:], want[:]) {
+			t.Fatalf("%s: hasher root does not match ValidatorRegistryRoot", step)
+		}
+	}
+	assertRootMatches("seed")
+
+	updated := newTestValidator(100)
+	vals[3] = updated
+	if err := h.Update(3, updated); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	assertRootMatches("update")
+
+	appended := newTestValidator(101)
+	vals = append(vals, appended)
+	if _, err := h.Append(appended); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	assertRootMatches("append")
+
+	vals[5] = nil
+	if err := h.Delete(5); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// ValidatorRegistryRoot can't re-derive a root over a nil entry, and
+	// Delete stores the zero leaf (not an empty validator's leaf root), so
+	// rebuild the expected root from the leaf roots with a zero leaf at
+	// index 5 instead of reusing assertRootMatches.
+	zeroed := make([]*ethpb.Validator, len(vals))
+	copy(zeroed, vals)
+	zeroed[5] = &ethpb.Validator{}
+	leaves, err := OptimizedValidatorRoots(zeroed)
+	if err != nil {
+		t.Fatalf("delete: OptimizedValidatorRoots: %v", err)
+	}
+	leaves[5] = [32]byte{}
+	got, err := h.Root()
+	if err != nil {
+		t.Fatalf("delete: Root: %v", err)
+	}
+	treeRoot, err := ssz.BitwiseMerkleize(leaves, uint64(len(leaves)), fieldparams.ValidatorRegistryLimit)
+	if err != nil {
+		t.Fatalf("delete: BitwiseMerkleize: %v", err)
+	}
+	want, err := mixInValidatorLength(treeRoot, uint64(len(leaves)))
+	if err != nil {
+		t.Fatalf("delete: mixInValidatorLength: %v", err)
+	}
+	if !bytes.Equal(got[:], want[:]) {
+		t.Fatal("delete: hasher root does not match the registry root with a zero leaf at the deleted index")
+	}
+}
+
+// TestValidatorRegistryHasher_ProofSurvivesDeleteElsewhere is a regression
+// test: Proof used to call ValidatorRegistryProof(h.validators, index)
+// directly, which derives field roots from h.validators and therefore
+// panicked for ANY index once a single entry had been tombstoned to nil
+// by Delete, even when proving a different, never-deleted index.
+func TestValidatorRegistryHasher_ProofSurvivesDeleteElsewhere(t *testing.T) {
+	vals := newTestValidators(6)
+	h, err := NewValidatorRegistryHasher(vals)
+	if err != nil {
+		t.Fatalf("NewValidatorRegistryHasher: %v", err)
+	}
+
+	if err := h.Delete(2); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	proof, err := h.Proof(4)
+	if err != nil {
+		t.Fatalf("Proof(4) after Delete(2): %v", err)
+	}
+
+	root, err := h.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+	ok, err := VerifyValidatorProof(vals[4], proof, root)
+	if err != nil {
+		t.Fatalf("VerifyValidatorProof: %v", err)
+	}
+	if !ok {
+		t.Fatal("proof for the untouched index did not verify after an unrelated Delete")
+	}
+}
+
+func TestValidatorRegistryHasher_Frontier(t *testing.T) {
+	vals := newTestValidators(5)
+	h, err := NewValidatorRegistryHasher(vals)
+	if err != nil {
+		t.Fatalf("NewValidatorRegistryHasher: %v", err)
+	}
+	frontier, err := h.Frontier()
+	if err != nil {
+		t.Fatalf("Frontier: %v", err)
+	}
+	want, err := NewPrefixFrontier(vals)
+	if err != nil {
+		t.Fatalf("NewPrefixFrontier: %v", err)
+	}
+	if frontier.Length != want.Length || len(frontier.Peaks) != len(want.Peaks) {
+		t.Fatalf("Frontier() = %+v, want %+v", frontier, want)
+	}
+	for i := range want.Peaks {
+		if frontier.Peaks[i] != want.Peaks[i] {
+			t.Fatalf("Frontier() peak %d = %+v, want %+v", i, frontier.Peaks[i], want.Peaks[i])
+		}
+	}
+
+	// An intervening Update should force Frontier to rebuild rather than
+	// silently reuse a peak that no longer reflects the updated leaf.
+	if err := h.Update(1, newTestValidator(200)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	vals[1] = newTestValidator(200)
+	frontier, err = h.Frontier()
+	if err != nil {
+		t.Fatalf("Frontier after Update: %v", err)
+	}
+	want, err = NewPrefixFrontier(vals)
+	if err != nil {
+		t.Fatalf("NewPrefixFrontier after Update: %v", err)
+	}
+	for i := range want.Peaks {
+		if frontier.Peaks[i] != want.Peaks[i] {
+			t.Fatalf("Frontier() after Update peak %d = %+v, want %+v", i, frontier.Peaks[i], want.Peaks[i])
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package stateutil
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/prysm/v5/crypto/hash"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+// PrefixProof shows that a validator registry of length NewLength
+// extends one of length OldLength, i.e. that the first OldLength leaves
+// are unchanged, without re-hashing either registry in full.
+//
+// Hashes has registryProofDepth+1 entries. Hashes[0] is the root of the
+// first newly appended validator. Hashes[h+1], for h in
+// [0, registryProofDepth), is the sibling at height h on the walk from
+// leaf OldLength: the left sibling when already committed by OldRoot, or
+// the right sibling drawn from the newly appended leaves otherwise.
+type PrefixProof struct {
+	OldLength uint64
+	NewLength uint64
+	Hashes    [][32]byte
+}
+
+// PrefixPeak is one maximal complete subtree of a validator registry, in
+// the classic Merkle Mountain Range decomposition: a registry of a given
+// length has exactly one peak per set bit of that length, and the peak
+// at height h (when present) is exactly the "already committed by
+// OldRoot" sibling ValidatorRegistryPrefixProof needs at that height.
+type PrefixPeak struct {
+	Height int
+	Hash   [32]byte
+}
+
+// PrefixFrontier holds the Merkle Mountain Range peaks of a validator
+// registry of the given Length. It is the minimal amount of information
+// needed to produce a ValidatorRegistryPrefixProof extending that
+// registry without re-merkleizing it. Build one with NewPrefixFrontier
+// (O(Length), a one-time cost) or, preferably, maintain one
+// incrementally via ValidatorRegistryHasher.Frontier, which keeps it
+// current in O(1) amortized time per Append.
+type PrefixFrontier struct {
+	Length uint64
+	Peaks  []PrefixPeak
+}
+
+func (f *PrefixFrontier) peakAtHeight(height int) ([32]byte, bool) {
+	for _, p := range f.Peaks {
+		if p.Height == height {
+			return p.Hash, true
+		}
+	}
+	return [32]byte{}, false
+}
+
+// pushPrefixPeak appends a single new leaf to a Merkle Mountain Range,
+// merging equal-height peaks from the top down until no two adjacent
+// peaks share a height. This is O(1) amortized per call.
+func pushPrefixPeak(peaks []PrefixPeak, leaf [32]byte) []PrefixPeak {
+	peaks = append(peaks, PrefixPeak{Height: 0, Hash: leaf})
+	for len(peaks) >= 2 && peaks[len(peaks)-1].Height == peaks[len(peaks)-2].Height {
+		b := peaks[len(peaks)-1]
+		a := peaks[len(peaks)-2]
+		peaks = peaks[:len(peaks)-2]
+		concat := append(a.Hash[:], b.Hash[:]...)
+		peaks = append(peaks, PrefixPeak{Height: a.Height + 1, Hash: hash.Hash(concat)})
+	}
+	return peaks
+}
+
+// NewPrefixFrontier computes the Merkle Mountain Range peaks for oldVals
+// from scratch, in O(len(oldVals)). Prefer
+// ValidatorRegistryHasher.Frontier when one is already being maintained,
+// since it keeps peaks current in O(1) amortized time per Append instead
+// of recomputing all of them here.
+func NewPrefixFrontier(oldVals []*ethpb.Validator) (*PrefixFrontier, error) {
+	roots, err := OptimizedValidatorRoots(oldVals)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get validator roots")
+	}
+	var peaks []PrefixPeak
+	for _, leaf := range roots {
+		peaks = pushPrefixPeak(peaks, leaf)
+	}
+	return &PrefixFrontier{Length: uint64(len(oldVals)), Peaks: peaks}, nil
+}
+
+// appendOnlyHash returns the hash of the leaf range
+// [blockStart, blockStart+2^height) of the registry, where blockStart is
+// guaranteed to be >= oldLen, using newRoots (the leaves appended after
+// oldLen) and the zero-hash chain beyond newLen. It only recurses into
+// ranges that actually overlap [oldLen, newLen), so its cost is bounded
+// by the number of newly appended validators rather than 2^height.
+func appendOnlyHash(height int, blockStart, oldLen, newLen uint64, newRoots [][32]byte) [32]byte {
+	if blockStart >= newLen {
+		return zeroHashes[height]
+	}
+	if height == 0 {
+		return newRoots[blockStart-oldLen]
+	}
+	half := uint64(1) << uint(height-1)
+	left := appendOnlyHash(height-1, blockStart, oldLen, newLen, newRoots)
+	right := appendOnlyHash(height-1, blockStart+half, oldLen, newLen, newRoots)
+	concat := append(left[:], right[:]...)
+	return hash.Hash(concat)
+}
+
+// ValidatorRegistryPrefixProof proves that the registry formed by
+// appending newVals to a registry of length oldFrontier.Length extends
+// that registry, so a verifier holding only OldRoot/NewRoot can confirm
+// the first oldFrontier.Length leaves did not change. oldFrontier need
+// only carry the O(log(oldFrontier.Length)) MMR peaks, not the old
+// validators themselves; build it with NewPrefixFrontier, or keep one
+// current via ValidatorRegistryHasher.Frontier.
+func ValidatorRegistryPrefixProof(oldFrontier *PrefixFrontier, newVals []*ethpb.Validator) (*PrefixProof, error) {
+	if oldFrontier == nil {
+		return nil, errors.New("oldFrontier must not be nil")
+	}
+	if len(newVals) == 0 {
+		return nil, errors.New("newVals must not be empty")
+	}
+
+	oldLen := oldFrontier.Length
+	newLen := oldLen + uint64(len(newVals))
+
+	newRoots, err := OptimizedValidatorRoots(newVals)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get new validator roots")
+	}
+
+	hashes := make([][32]byte, registryProofDepth+1)
+	hashes[0] = newRoots[0]
+	for h := 0; h < registryProofDepth; h++ {
+		position := oldLen >> uint(h)
+		if position%2 == 1 {
+			// The left sibling's entire range is <= oldLen, i.e. already
+			// committed by OldRoot, and is exactly the MMR peak at height h.
+			sibling, ok := oldFrontier.peakAtHeight(h)
+			if !ok {
+				sibling = zeroHashes[h]
+			}
+			hashes[h+1] = sibling
+		} else {
+			// The right sibling's entire range is >= oldLen, i.e. drawn
+			// from the newly appended leaves (or zero beyond newLen).
+			blockStart := (position + 1) << uint(h)
+			hashes[h+1] = appendOnlyHash(h, blockStart, oldLen, newLen, newRoots)
+		}
+	}
+
+	return &PrefixProof{OldLength: oldLen, NewLength: newLen, Hashes: hashes}, nil
+}
+
+// VerifyValidatorRegistryPrefix verifies a PrefixProof by folding both
+// OldRoot and NewRoot from the same shared sibling chain and checking
+// each against the corresponding root.
+func VerifyValidatorRegistryPrefix(oldRoot, newRoot [32]byte, oldLen, newLen uint64, proof *PrefixProof) (bool, error) {
+	if proof == nil || len(proof.Hashes) != registryProofDepth+1 {
+		return false, errors.New("invalid prefix proof depth")
+	}
+	if proof.OldLength != oldLen || proof.NewLength != newLen {
+		return false, errors.New("prefix proof length mismatch")
+	}
+
+	oldCurrent := zeroHashes[0]
+	newCurrent := proof.Hashes[0]
+	for h := 0; h < registryProofDepth; h++ {
+		sibling := proof.Hashes[h+1]
+		position := oldLen >> uint(h)
+		if position%2 == 1 {
+			oldCurrent = hash.Hash(append(sibling[:], oldCurrent[:]...))
+			newCurrent = hash.Hash(append(sibling[:], newCurrent[:]...))
+		} else {
+			oldCurrent = hash.Hash(append(oldCurrent[:], zeroHashes[h][:]...))
+			newCurrent = hash.Hash(append(newCurrent[:], sibling[:]...))
+		}
+	}
+
+	gotOldRoot, err := mixInValidatorLength(oldCurrent, oldLen)
+	if err != nil {
+		return false, err
+	}
+	gotNewRoot, err := mixInValidatorLength(newCurrent, newLen)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(gotOldRoot[:], oldRoot[:]) && bytes.Equal(gotNewRoot[:], newRoot[:]), nil
+}
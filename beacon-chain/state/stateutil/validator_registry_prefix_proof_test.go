@@ -0,0 +1,119 @@
+package stateutil
+
+import (
+	"testing"
+
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+)
+
+func TestValidatorRegistryPrefixProof_RoundTrip(t *testing.T) {
+	cases := []struct {
+		oldLen, newLen int
+	}{
+		{0, 1},
+		{1, 2},
+		{1, 9},
+		{5, 6},
+		{7, 8},
+		{8, 9},
+		{15, 16},
+		{16, 17},
+		{16, 32},
+		{20, 37},
+	}
+	for _, c := range cases {
+		oldVals := newTestValidators(c.oldLen)
+		allVals := newTestValidators(c.newLen)
+		newVals := allVals[c.oldLen:]
+
+		oldRoot, err := ValidatorRegistryRoot(oldVals)
+		if err != nil {
+			t.Fatalf("oldLen=%d newLen=%d: ValidatorRegistryRoot(old): %v", c.oldLen, c.newLen, err)
+		}
+		newRoot, err := ValidatorRegistryRoot(allVals)
+		if err != nil {
+			t.Fatalf("oldLen=%d newLen=%d: ValidatorRegistryRoot(new): %v", c.oldLen, c.newLen, err)
+		}
+
+		frontier, err := NewPrefixFrontier(oldVals)
+		if err != nil {
+			t.Fatalf("oldLen=%d newLen=%d: NewPrefixFrontier: %v", c.oldLen, c.newLen, err)
+		}
+		proof, err := ValidatorRegistryPrefixProof(frontier, newVals)
+		if err != nil {
+			t.Fatalf("oldLen=%d newLen=%d: ValidatorRegistryPrefixProof: %v", c.oldLen, c.newLen, err)
+		}
+
+		ok, err := VerifyValidatorRegistryPrefix(oldRoot, newRoot, uint64(c.oldLen), uint64(c.newLen), proof)
+		if err != nil {
+			t.Fatalf("oldLen=%d newLen=%d: VerifyValidatorRegistryPrefix: %v", c.oldLen, c.newLen, err)
+		}
+		if !ok {
+			t.Fatalf("oldLen=%d newLen=%d: prefix proof did not verify against the real registry roots", c.oldLen, c.newLen)
+		}
+	}
+}
+
+func TestValidatorRegistryPrefixProof_MatchesHasherFrontier(t *testing.T) {
+	oldVals := newTestValidators(11)
+	h, err := NewValidatorRegistryHasher(oldVals)
+	if err != nil {
+		t.Fatalf("NewValidatorRegistryHasher: %v", err)
+	}
+	oldRoot, err := h.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+
+	newVals := newTestValidators(17)[11:]
+	allVals := append(append([]*ethpb.Validator{}, oldVals...), newVals...)
+	newRoot, err := ValidatorRegistryRoot(allVals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryRoot: %v", err)
+	}
+
+	frontier, err := h.Frontier()
+	if err != nil {
+		t.Fatalf("Frontier: %v", err)
+	}
+	proof, err := ValidatorRegistryPrefixProof(frontier, newVals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryPrefixProof: %v", err)
+	}
+	ok, err := VerifyValidatorRegistryPrefix(oldRoot, newRoot, uint64(len(oldVals)), uint64(len(allVals)), proof)
+	if err != nil {
+		t.Fatalf("VerifyValidatorRegistryPrefix: %v", err)
+	}
+	if !ok {
+		t.Fatal("prefix proof built from a hasher-maintained frontier did not verify")
+	}
+}
+
+func TestValidatorRegistryPrefixProof_RejectsWrongLength(t *testing.T) {
+	oldVals := newTestValidators(4)
+	newVals := newTestValidators(2)
+	frontier, err := NewPrefixFrontier(oldVals)
+	if err != nil {
+		t.Fatalf("NewPrefixFrontier: %v", err)
+	}
+	proof, err := ValidatorRegistryPrefixProof(frontier, newVals)
+	if err != nil {
+		t.Fatalf("ValidatorRegistryPrefixProof: %v", err)
+	}
+	if _, err := VerifyValidatorRegistryPrefix([32]byte{}, [32]byte{}, 4, 7, proof); err == nil {
+		t.Fatal("expected an error for a newLen mismatch against the proof")
+	}
+}
+
+func TestValidatorRegistryPrefixProof_RejectsEmptyInputs(t *testing.T) {
+	frontier, err := NewPrefixFrontier(nil)
+	if err != nil {
+		t.Fatalf("NewPrefixFrontier: %v", err)
+	}
+	if _, err := ValidatorRegistryPrefixProof(frontier, nil); err == nil {
+		t.Fatal("expected an error for no newVals")
+	}
+	if _, err := ValidatorRegistryPrefixProof(nil, newTestValidators(1)); err == nil {
+		t.Fatal("expected an error for a nil frontier")
+	}
+}